@@ -0,0 +1,65 @@
+// Package command implements the dispatcher behind Amfora's `:` command
+// mode. It knows nothing about cview or the display package - callers
+// register Commands at startup, and the bottom bar and an rc file just call
+// Run with a line of text.
+package command
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Context carries the arguments a Command was invoked with, split on
+// whitespace, with the command name itself already removed.
+type Context struct {
+	Args []string
+}
+
+// Command is a single named action that can be run from the `:` bottom bar
+// or a startup rc file.
+type Command struct {
+	Name string
+	Run  func(ctx Context) error
+}
+
+var registry = make(map[string]Command)
+
+// Register adds a command to the dispatcher, keyed by its Name. It's called
+// at startup, typically from display.Init().
+func Register(cmd Command) {
+	registry[cmd.Name] = cmd
+}
+
+// Run parses a line like "tabnew gemini://example.com" and runs the
+// matching registered Command. It returns an error if the line is empty,
+// the command name isn't registered, or the Command itself fails.
+func Run(line string) error {
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return fmt.Errorf("no command given")
+	}
+
+	cmd, ok := registry[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown command: %s", fields[0])
+	}
+	return cmd.Run(Context{Args: fields[1:]})
+}
+
+// RunFile runs each non-empty, non-comment line of an rc-style file in
+// order, collecting and returning every error encountered rather than
+// stopping at the first one - a typo in one line of a startup script
+// shouldn't stop the rest of it from running.
+func RunFile(lines []string) []error {
+	var errs []error
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if err := Run(line); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", line, err))
+		}
+	}
+	return errs
+}
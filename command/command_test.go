@@ -0,0 +1,79 @@
+package command
+
+import "testing"
+
+func TestRunUnknownCommand(t *testing.T) {
+	if err := Run("nosuchcommand foo"); err == nil {
+		t.Fatal("expected an error for an unregistered command, got nil")
+	}
+}
+
+func TestRunEmptyLine(t *testing.T) {
+	if err := Run(""); err == nil {
+		t.Fatal("expected an error for an empty line, got nil")
+	}
+}
+
+func TestRunDispatchesArgs(t *testing.T) {
+	var got []string
+	Register(Command{Name: "echo", Run: func(ctx Context) error {
+		got = ctx.Args
+		return nil
+	}})
+
+	if err := Run("echo one two three"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"one", "two", "three"}
+	if len(got) != len(want) {
+		t.Fatalf("got args %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("got args %v, want %v", got, want)
+		}
+	}
+}
+
+func TestRunFileSkipsBlankAndCommentLines(t *testing.T) {
+	var ran int
+	Register(Command{Name: "count", Run: func(ctx Context) error {
+		ran++
+		return nil
+	}})
+
+	errs := RunFile([]string{
+		"",
+		"  ",
+		"# a comment",
+		"count",
+		"count",
+	})
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if ran != 2 {
+		t.Fatalf("got %d runs, want 2", ran)
+	}
+}
+
+func TestRunFileCollectsErrorsWithoutStopping(t *testing.T) {
+	var ran int
+	Register(Command{Name: "countok", Run: func(ctx Context) error {
+		ran++
+		return nil
+	}})
+
+	errs := RunFile([]string{
+		"nosuchcommand",
+		"countok",
+		"nosuchcommand",
+	})
+	if len(errs) != 2 {
+		t.Fatalf("got %d errors, want 2", len(errs))
+	}
+	if ran != 1 {
+		t.Fatalf("got %d runs, want 1 - a bad line shouldn't stop the rest of the file", ran)
+	}
+}
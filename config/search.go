@@ -0,0 +1,12 @@
+package config
+
+import "github.com/spf13/viper"
+
+// SearchEngines returns the configured [search] table, mapping an engine
+// name to its URL template (which contains a %s for the escaped query).
+// Both the bottom bar and `:search` use this to resolve a query, and other
+// callers (bookmarks, command mode) can reuse it instead of re-reading
+// viper directly.
+func SearchEngines() map[string]string {
+	return viper.GetStringMapString("search")
+}
@@ -1,14 +1,19 @@
 package display
 
 import (
+	"bufio"
 	"fmt"
 	"net/url"
+	"os"
 	"path"
+	"path/filepath"
 	"strconv"
 	"strings"
+	"sync"
 
 	"github.com/gdamore/tcell"
 	"github.com/makeworld-the-better-one/amfora/cache"
+	"github.com/makeworld-the-better-one/amfora/command"
 	"github.com/makeworld-the-better-one/amfora/config"
 	"github.com/makeworld-the-better-one/amfora/renderer"
 	"github.com/makeworld-the-better-one/amfora/structs"
@@ -19,6 +24,21 @@ import (
 var tabs []*tab // Slice of all the current browser tabs
 var curTab = -1 // What tab is currently visible - index for the tabs slice (-1 means there are no tabs)
 
+// tabIDs holds a stable, monotonically increasing ID for each entry in tabs.
+// tabPages pages are named after these IDs rather than the tab's position, so
+// that closing or moving a tab never invalidates the page names of its
+// neighbours. tabRow regions, on the other hand, are still keyed by visible
+// position - see rewriteTabRow.
+var tabIDs []int
+var nextTabID int
+
+// tabsMu guards tabs, tabIDs, curTab and the tabRow/tabPages state derived
+// from them. Background tabs mean more than one goroutine can now
+// finish loading and call rewriteTabRow at the same time as the main event
+// loop is closing or reordering tabs, so every function that reads or
+// writes these needs to hold it.
+var tabsMu sync.Mutex
+
 // Terminal dimensions
 var termW int
 var termH int
@@ -44,7 +64,7 @@ var tabRow = cview.NewTextView().
 		// There will always only be one string in added - never multiple highlights
 		// Remaining should always be empty
 		i, _ := strconv.Atoi(added[0])
-		tabPages.SwitchToPage(strconv.Itoa(i)) // Tab names are just numbers, zero-indexed
+		tabPages.SwitchToPage(strconv.Itoa(tabIDs[i])) // Regions are positions, pages are IDs
 	})
 
 // Root layout
@@ -55,6 +75,125 @@ var renderedNewTabContent string
 var newTabLinks []string
 var newTabPage structs.Page
 
+// Link-hint mode: pressing F overlays every link on the current page with a
+// short letter sequence, and typing that sequence in bottomBar follows it
+// without needing to count link numbers on long pages.
+var hintMode bool
+var hintTargets map[string]int // Hint sequence, ex. "as" -> 0-indexed link number
+
+// cmdMode is true while bottomBar is reading a `:` command line.
+var cmdMode bool
+
+// hintAlphabet is used to build hint sequences, single letters first and
+// then two-letter combinations once the page has more links than letters.
+const hintAlphabet = "asdfghjklqwertyuiopzxcvbnm"
+
+// hintSequences returns n unique hint strings, shortest first, such that no
+// hint is a prefix of another one - otherwise typing the first letter of a
+// two-letter hint would immediately (and wrongly) match a one-letter hint
+// that happens to share it. A leading block of single letters is reserved
+// for 1-letter hints; the rest of the alphabet is only ever used to start a
+// 2-letter hint, so the two never collide.
+func hintSequences(n int) []string {
+	a := len(hintAlphabet)
+	if n <= a {
+		seqs := make([]string, n)
+		for i := 0; i < n; i++ {
+			seqs[i] = string(hintAlphabet[i])
+		}
+		return seqs
+	}
+
+	// Find the largest number of 1-letter hints s such that the remaining
+	// (a-s) leading letters still produce enough 2-letter combinations to
+	// cover the rest: s + (a-s)*a >= n.
+	s := a
+	for s > 0 && s+(a-s)*a < n {
+		s--
+	}
+
+	seqs := make([]string, 0, n)
+	for i := 0; i < s; i++ {
+		seqs = append(seqs, string(hintAlphabet[i]))
+	}
+	for i := s; i < a && len(seqs) < n; i++ {
+		for j := 0; j < a && len(seqs) < n; j++ {
+			seqs = append(seqs, string(hintAlphabet[i])+string(hintAlphabet[j]))
+		}
+	}
+	return seqs
+}
+
+// enterHintMode overlays hint sequences on every link of the current tab and
+// switches bottomBar to accept one.
+func enterHintMode() {
+	t := tabs[curTab]
+	if !t.hasContent() || len(t.page.Links) == 0 {
+		return
+	}
+
+	seqs := hintSequences(len(t.page.Links))
+	hintTargets = make(map[string]int, len(seqs))
+	for i, seq := range seqs {
+		hintTargets[seq] = i
+	}
+
+	content, _ := renderer.RenderGeminiHints(t.page.Raw, textWidth(), leftMargin(), seqs)
+	t.view.SetText(content)
+
+	hintMode = true
+	bottomBar.SetLabel("[::b]Link hint (;+hint opens in new tab): [::-]")
+	bottomBar.SetText("")
+	App.SetFocus(bottomBar)
+}
+
+// exitHintMode removes the hint overlay and restores the tab's normal content.
+func exitHintMode() {
+	if !hintMode {
+		return
+	}
+	hintMode = false
+	hintTargets = nil
+	reformatPageAndSetView(tabs[curTab], tabs[curTab].page)
+}
+
+// resolveHintInput parses bottomBar's content while in hint mode, optionally
+// prefixed with ";" to open the link in a new tab instead of the current
+// one. ";" is used rather than a letter because hintAlphabet uses every
+// letter of the alphabet for hints themselves - any letter prefix would be
+// indistinguishable from a real one- or two-letter hint.
+func resolveHintInput(query string) {
+	newTab := false
+	if strings.HasPrefix(query, ";") && len(query) > 1 {
+		newTab = true
+		query = query[1:]
+	}
+
+	i, ok := hintTargets[query]
+	if !ok {
+		// Not a complete/valid sequence yet, keep waiting for more input
+		return
+	}
+
+	t := tabs[curTab]
+	link := t.page.Links[i]
+	exitHintMode()
+
+	if newTab {
+		oldTab := curTab
+		NewTab()
+		next, err := resolveRelLink(tabs[oldTab], tabs[oldTab].page.Url, link)
+		if err != nil {
+			Error("URL Error", err.Error())
+			return
+		}
+		URL(next)
+		return
+	}
+
+	followLink(t, t.page.Url, link)
+}
+
 var App = cview.NewApplication().
 	EnableMouse(false).
 	SetRoot(layout, true).
@@ -102,6 +241,11 @@ func Init() {
 			SetFieldBackgroundColor(tcell.ColorWhite).
 			SetFieldTextColor(tcell.ColorBlack)
 	}
+	bottomBar.SetChangedFunc(func(text string) {
+		if hintMode {
+			resolveHintInput(text)
+		}
+	})
 	bottomBar.SetDoneFunc(func(key tcell.Key) {
 		tab := curTab
 
@@ -111,17 +255,33 @@ func Init() {
 		// Use for errors.
 		reset := func() {
 			bottomBar.SetLabel("")
+			exitHintMode()
+			cmdMode = false
 			tabs[tab].applyAll()
 			App.SetFocus(tabs[tab].view)
 		}
 
 		switch key {
 		case tcell.KeyEnter:
+			query := bottomBar.GetText()
+
+			if cmdMode {
+				cmdMode = false
+				if err := command.Run(query); err != nil {
+					Error("Command Error", err.Error())
+				}
+				reset()
+				return
+			}
+
+			if hintMode {
+				resolveHintInput(query)
+				return
+			}
+
 			// Figure out whether it's a URL, link number, or search
 			// And send out a request
 
-			query := bottomBar.GetText()
-
 			if strings.TrimSpace(query) == "" {
 				// Ignore
 				reset()
@@ -153,33 +313,46 @@ func Init() {
 
 			i, err := strconv.Atoi(query)
 			if err != nil {
-				if strings.HasPrefix(query, "new:") && len(query) > 4 {
-					// They're trying to open a link number in a new tab
-					i, err = strconv.Atoi(query[4:])
+				background := strings.HasPrefix(query, "bgnew:")
+				prefix := "new:"
+				if background {
+					prefix = "bgnew:"
+				}
+				if strings.HasPrefix(query, prefix) && len(query) > len(prefix) {
+					// They're trying to open a link number in a new tab,
+					// in the background if they used the bgnew: prefix
+					i, err = strconv.Atoi(query[len(prefix):])
 					if err != nil {
 						reset()
 						return
 					}
 					if i <= len(tabs[tab].page.Links) && i > 0 {
-						// Open new tab and load link
-						oldTab := tab
-						NewTab()
-						// Resolve and follow link manually
-						prevParsed, _ := url.Parse(tabs[oldTab].page.Url)
-						nextParsed, err := url.Parse(tabs[oldTab].page.Links[i-1])
+						// Resolve the link relative to the current page
+						next, err := resolveRelLink(tabs[tab], tabs[tab].page.Url, tabs[tab].page.Links[i-1])
 						if err != nil {
-							Error("URL Error", "link URL could not be parsed")
+							Error("URL Error", err.Error())
 							reset()
 							return
 						}
-						URL(prevParsed.ResolveReference(nextParsed).String())
+						if background {
+							NewBackgroundTab(next)
+							reset()
+						} else {
+							NewTab()
+							URL(next)
+						}
 						return
 					}
 				} else {
 					// It's a full URL or search term
 					// Detect if it's a search or URL
 					if strings.Contains(query, " ") || (!strings.Contains(query, "//") && !strings.Contains(query, ".") && !strings.HasPrefix(query, "about:")) {
-						u := viper.GetString("a-general.search") + "?" + queryEscape(query)
+						u, ok := resolveSearch(query)
+						if !ok {
+							Error("Search Error", "no default search engine is configured")
+							reset()
+							return
+						}
 						cache.RemovePage(u) // Don't use the cached version of the search
 						URL(u)
 					} else {
@@ -220,6 +393,9 @@ func Init() {
 
 	modalInit()
 
+	registerCommands()
+	runRCFile()
+
 	// Setup map of keys to functions here
 	// Changing tabs, new tab, etc
 	App.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
@@ -262,8 +438,7 @@ func Init() {
 				URL(viper.GetString("a-general.home"))
 				return nil
 			case tcell.KeyCtrlB:
-				Bookmarks(tabs[curTab])
-				tabs[curTab].addToHistory("about:bookmarks")
+				openBookmarks()
 				return nil
 			case tcell.KeyCtrlD:
 				go addBookmark()
@@ -275,16 +450,7 @@ func Init() {
 				tabs[curTab].pageDown()
 				return nil
 			case tcell.KeyCtrlS:
-				if tabs[curTab].hasContent() {
-					savePath, err := downloadPage(tabs[curTab].page)
-					if err != nil {
-						Error("Download Error", fmt.Sprintf("Error saving page content: %v", err))
-					} else {
-						Info(fmt.Sprintf("Page content saved to %s. ", savePath))
-					}
-				} else {
-					Info("The current page has no content, so it couldn't be downloaded.")
-				}
+				downloadCurrentTab()
 				return nil
 			case tcell.KeyRune:
 				// Regular key was sent
@@ -296,6 +462,12 @@ func Init() {
 					// Don't save bottom bar, so that whenever you switch tabs, it's not in that mode
 					App.SetFocus(bottomBar)
 					return nil
+				case ":":
+					bottomBar.SetLabel("[::b]:[::-]")
+					bottomBar.SetText("")
+					cmdMode = true
+					App.SetFocus(bottomBar)
+					return nil
 				case "R":
 					Reload()
 					return nil
@@ -311,6 +483,9 @@ func Init() {
 				case "d":
 					tabs[curTab].pageDown()
 					return nil
+				case "F":
+					enterHintMode()
+					return nil
 				}
 
 				// Number key: 1-9, 0
@@ -329,22 +504,43 @@ func Init() {
 		}
 		// All the keys and operations that can work while a tab IS loading
 
+		if event.Modifiers() == tcell.ModCtrl|tcell.ModShift {
+			if event.Key() == tcell.KeyLeft {
+				MoveTab(curTab, curTab-1)
+				return nil
+			}
+			if event.Key() == tcell.KeyRight {
+				MoveTab(curTab, curTab+1)
+				return nil
+			}
+		}
+
 		switch event.Key() {
 		case tcell.KeyCtrlT:
+			// Shift flips the a-general.open_in_background default, so
+			// Ctrl+Shift+T gives the opposite of Ctrl+T's usual behavior.
+			background := viper.GetBool("a-general.open_in_background")
+			if event.Modifiers()&tcell.ModShift != 0 {
+				background = !background
+			}
 			if tabs[curTab].page.Mode == structs.ModeLinkSelect {
 				next, err := resolveRelLink(tabs[curTab], tabs[curTab].page.Url, tabs[curTab].page.Selected)
 				if err != nil {
 					Error("URL Error", err.Error())
 					return nil
 				}
-				NewTab()
-				URL(next)
+				if background {
+					NewBackgroundTab(next)
+				} else {
+					NewTab()
+					URL(next)
+				}
 			} else {
 				NewTab()
 			}
 			return nil
 		case tcell.KeyCtrlW:
-			CloseTab()
+			CloseTab(curTab)
 			return nil
 		case tcell.KeyCtrlQ:
 			Stop()
@@ -395,6 +591,8 @@ func NewTab() {
 	// SetDoneFunc to do link highlighting
 	// Add view to pages and switch to it
 
+	tabsMu.Lock()
+
 	// Process current tab before making a new one
 	if curTab > -1 {
 		// Turn off link selecting mode in the current tab
@@ -407,6 +605,9 @@ func NewTab() {
 	curTab = NumTabs()
 
 	tabs = append(tabs, makeNewTab())
+	id := nextTabID
+	nextTabID++
+	tabIDs = append(tabIDs, id)
 	temp := newTabPage // Copy
 	setPage(tabs[curTab], &temp)
 
@@ -414,7 +615,9 @@ func NewTab() {
 	// The first page will be the next one the user goes to.
 	tabs[curTab].history.pos = -1
 
-	tabPages.AddAndSwitchToPage(strconv.Itoa(curTab), tabs[curTab].view, true)
+	tabPages.AddAndSwitchToPage(strconv.Itoa(id), tabs[curTab].view, true)
+	tabsMu.Unlock()
+
 	App.SetFocus(tabs[curTab].view)
 
 	// Add tab number to the actual place where tabs are show on the screen
@@ -439,14 +642,52 @@ func NewTab() {
 	App.Draw()
 }
 
-// CloseTab closes the current tab and switches to the one to its left.
-func CloseTab() {
+// NewBackgroundTab opens a new tab and starts loading the given URL in it,
+// like NewTab followed by URL, but without switching focus or highlighting
+// to the new tab - for opening many links from a page without being
+// disruptive. The tab row shows the new tab as loading until it's done.
+func NewBackgroundTab(u string) {
+	tabsMu.Lock()
+
+	id := nextTabID
+	nextTabID++
+
+	tabs = append(tabs, makeNewTab())
+	tabIDs = append(tabIDs, id)
+	idx := len(tabs) - 1
+	t := tabs[idx]
+
+	temp := newTabPage // Copy
+	setPage(t, &temp)
+	t.history.pos = -1
+
+	tabPages.AddPage(strconv.Itoa(id), t.view, true, false)
+	rewriteTabRow()
+
+	tabsMu.Unlock()
+
+	go func(t *tab) {
+		goURL(t, u)
+		// Only the visible tab's bottomBar/mode state should change -
+		// goURL already confines itself to t, so just refresh the tab
+		// row's loading/finished marker for this tab once it's done.
+		tabsMu.Lock()
+		rewriteTabRow()
+		tabsMu.Unlock()
+		App.Draw()
+	}(t)
+}
+
+// CloseTab closes the tab at the given visible position. If that was the
+// current tab, the one to its left is shown instead (or the last tab, if the
+// closed one was the first); otherwise the current tab stays shown. Any tab
+// can be closed, not just the right-most one - the
+// tabs to the right of it just shift left, they keep their own tab IDs and
+// so their tabPages pages are unaffected.
+func CloseTab(index int) {
 	// Basically the NewTab() func inverted
 
-	// TODO: Support closing middle tabs, by renumbering all the maps
-	// So that tabs to the right of the closed tabs point to the right places
-	// For now you can only close the right-most tab
-	if curTab != NumTabs()-1 {
+	if index < 0 || index > NumTabs()-1 {
 		return
 	}
 
@@ -456,17 +697,26 @@ func CloseTab() {
 		return
 	}
 
-	tabs = tabs[:len(tabs)-1]
-	tabPages.RemovePage(strconv.Itoa(curTab))
+	tabsMu.Lock()
 
-	if curTab <= 0 {
-		curTab = NumTabs() - 1
-	} else {
-		curTab--
+	tabPages.RemovePage(strconv.Itoa(tabIDs[index]))
+	tabs = append(tabs[:index], tabs[index+1:]...)
+	tabIDs = append(tabIDs[:index], tabIDs[index+1:]...)
+
+	if curTab >= index {
+		// The closed tab was the current one, or to its left
+		if curTab <= 0 {
+			curTab = NumTabs() - 1
+		} else {
+			curTab--
+		}
 	}
 
-	tabPages.SwitchToPage(strconv.Itoa(curTab)) // Go to previous page
+	tabPages.SwitchToPage(strconv.Itoa(tabIDs[curTab])) // Go to previous page
 	rewriteTabRow()
+
+	tabsMu.Unlock()
+
 	// Restore previous tab's state
 	tabs[curTab].applyAll()
 
@@ -476,6 +726,49 @@ func CloseTab() {
 	App.Draw()
 }
 
+// MoveTab moves the tab at visible position from to visible position to,
+// shifting the tabs in between, and keeps the currently displayed tab
+// selected even though its position may have changed.
+func MoveTab(from, to int) {
+	if from < 0 || from > NumTabs()-1 {
+		return
+	}
+	if to < 0 {
+		to = 0
+	}
+	if to > NumTabs()-1 {
+		to = NumTabs() - 1
+	}
+	if from == to {
+		return
+	}
+
+	tabsMu.Lock()
+
+	movedID := tabIDs[from]
+	t := tabs[from]
+
+	tabs = append(tabs[:from], tabs[from+1:]...)
+	tabIDs = append(tabIDs[:from], tabIDs[from+1:]...)
+
+	tabs = append(tabs[:to], append([]*tab{t}, tabs[to:]...)...)
+	tabIDs = append(tabIDs[:to], append([]int{movedID}, tabIDs[to:]...)...)
+
+	if curTab == from {
+		curTab = to
+	} else if from < curTab && curTab <= to {
+		curTab--
+	} else if to <= curTab && curTab < from {
+		curTab++
+	}
+
+	rewriteTabRow()
+
+	tabsMu.Unlock()
+
+	App.Draw()
+}
+
 // SwitchTab switches to a specific tab, using its number, 0-indexed.
 // The tab numbers are clamped to the end, so for example numbers like -5 and 1000 are still valid.
 // This means that calling something like SwitchTab(curTab - 1) will never cause an error.
@@ -487,6 +780,8 @@ func SwitchTab(tab int) {
 		tab = NumTabs() - 1
 	}
 
+	tabsMu.Lock()
+
 	// Save current tab attributes
 	if curTab > -1 {
 		// Save bottomBar state
@@ -498,10 +793,12 @@ func SwitchTab(tab int) {
 
 	// Display tab
 	reformatPageAndSetView(tabs[curTab], tabs[curTab].page)
-	tabPages.SwitchToPage(strconv.Itoa(curTab))
+	tabPages.SwitchToPage(strconv.Itoa(tabIDs[curTab]))
 	tabRow.Highlight(strconv.Itoa(curTab)).ScrollToHighlight()
 	tabs[curTab].applyAll()
 
+	tabsMu.Unlock()
+
 	App.SetFocus(tabs[curTab].view)
 
 	// Just in case
@@ -551,3 +848,196 @@ func URL(u string) {
 func NumTabs() int {
 	return len(tabs)
 }
+
+// errNoTab is returned by commands that need a tab to operate on - reachable
+// from an rc file run during Init(), before the first tab has been opened.
+var errNoTab = fmt.Errorf("no tab is open yet; use tabnew first")
+
+// requireTab is a guard for command Run funcs that dereference tabs[curTab].
+func requireTab() error {
+	if curTab < 0 {
+		return errNoTab
+	}
+	return nil
+}
+
+// downloadCurrentTab saves the current tab's page content and reports the
+// result, shared by the Ctrl+S keybinding and the :download command so they
+// can't drift apart.
+func downloadCurrentTab() error {
+	if !tabs[curTab].hasContent() {
+		Info("The current page has no content, so it couldn't be downloaded.")
+		return nil
+	}
+	savePath, err := downloadPage(tabs[curTab].page)
+	if err != nil {
+		Error("Download Error", fmt.Sprintf("Error saving page content: %v", err))
+		return err
+	}
+	Info(fmt.Sprintf("Page content saved to %s. ", savePath))
+	return nil
+}
+
+// openBookmarks shows the bookmarks page for the current tab, shared by the
+// Ctrl+B keybinding and the :bookmark command.
+func openBookmarks() {
+	Bookmarks(tabs[curTab])
+	tabs[curTab].addToHistory("about:bookmarks")
+}
+
+// registerCommands wires up the `:` command-mode dispatcher. Where a
+// keybinding does the same thing as a command, both call the same function
+// (ex. NewTab, Reload, openBookmarks, downloadCurrentTab), so that keys and
+// `:`-commands can't drift apart.
+func registerCommands() {
+	command.Register(command.Command{Name: "tabnew", Run: func(ctx command.Context) error {
+		NewTab()
+		if len(ctx.Args) > 0 {
+			URL(strings.Join(ctx.Args, " "))
+		}
+		return nil
+	}})
+	command.Register(command.Command{Name: "tabclose", Run: func(ctx command.Context) error {
+		if err := requireTab(); err != nil {
+			return err
+		}
+		if len(ctx.Args) == 0 {
+			CloseTab(curTab)
+			return nil
+		}
+		n, err := strconv.Atoi(ctx.Args[0])
+		if err != nil {
+			return fmt.Errorf("invalid tab number: %s", ctx.Args[0])
+		}
+		CloseTab(n - 1)
+		return nil
+	}})
+	command.Register(command.Command{Name: "bookmark", Run: func(ctx command.Context) error {
+		if err := requireTab(); err != nil {
+			return err
+		}
+		if len(ctx.Args) > 0 && ctx.Args[0] == "add" {
+			go addBookmark()
+			return nil
+		}
+		openBookmarks()
+		return nil
+	}})
+	command.Register(command.Command{Name: "set", Run: func(ctx command.Context) error {
+		if len(ctx.Args) != 1 {
+			return fmt.Errorf("usage: set key=value")
+		}
+		kv := strings.SplitN(ctx.Args[0], "=", 2)
+		if len(kv) != 2 {
+			return fmt.Errorf("usage: set key=value")
+		}
+		viper.Set(kv[0], kv[1])
+		return nil
+	}})
+	command.Register(command.Command{Name: "reload", Run: func(ctx command.Context) error {
+		if err := requireTab(); err != nil {
+			return err
+		}
+		Reload()
+		return nil
+	}})
+	command.Register(command.Command{Name: "open", Run: func(ctx command.Context) error {
+		if err := requireTab(); err != nil {
+			return err
+		}
+		if len(ctx.Args) == 0 {
+			return fmt.Errorf("usage: open <url>")
+		}
+		URL(strings.Join(ctx.Args, " "))
+		return nil
+	}})
+	command.Register(command.Command{Name: "search", Run: func(ctx command.Context) error {
+		if err := requireTab(); err != nil {
+			return err
+		}
+		if len(ctx.Args) < 2 {
+			return fmt.Errorf("usage: search <engine> <query>")
+		}
+		if _, ok := config.SearchEngines()[ctx.Args[0]]; !ok {
+			return fmt.Errorf("unknown search engine: %s", ctx.Args[0])
+		}
+		u, ok := resolveSearch("!" + ctx.Args[0] + " " + strings.Join(ctx.Args[1:], " "))
+		if !ok {
+			return fmt.Errorf("unknown search engine: %s", ctx.Args[0])
+		}
+		URL(u)
+		return nil
+	}})
+	command.Register(command.Command{Name: "download", Run: func(ctx command.Context) error {
+		if err := requireTab(); err != nil {
+			return err
+		}
+		return downloadCurrentTab()
+	}})
+	command.Register(command.Command{Name: "history", Run: func(ctx command.Context) error {
+		// There's no browsable history list to show here yet - only
+		// per-tab back/forward navigation (histBack/histForward, bound to
+		// "b"/"f"). Report this as a command error, same as any other
+		// unsupported command, rather than quietly doing nothing.
+		return fmt.Errorf("history view is not implemented yet")
+	}})
+}
+
+// runRCFile runs ~/.config/amfora/rc on startup, if it exists, letting
+// users script their session with the same commands available in `:` mode.
+func runRCFile() {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return
+	}
+
+	f, err := os.Open(filepath.Join(home, ".config", "amfora", "rc"))
+	if err != nil {
+		// No rc file, nothing to do
+		return
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+
+	for _, err := range command.RunFile(lines) {
+		Error("RC File Error", err.Error())
+	}
+}
+
+// resolveSearch turns a bottomBar query into a search URL, using the
+// [search] table in the config (name -> URL template containing a %s for
+// the escaped query) and the a-general.default_search_engine setting.
+//
+// A query can also start with a keyword/bang prefix naming one of the
+// configured engines directly, ex. "g foo bar" or "!w gemini", in which
+// case that engine is used instead of the default one. ok is false if
+// there's no matching engine and no default is configured, so the caller
+// can report an error instead of sending a request to an empty URL.
+func resolveSearch(query string) (string, bool) {
+	engines := config.SearchEngines()
+
+	if fields := strings.SplitN(query, " ", 2); len(fields) == 2 {
+		keyword := strings.TrimPrefix(fields[0], "!")
+		if tmpl, ok := engines[keyword]; ok {
+			return strings.Replace(tmpl, "%s", queryEscape(fields[1]), 1), true
+		}
+	}
+
+	name := viper.GetString("a-general.default_search_engine")
+	if tmpl, ok := engines[name]; ok {
+		return strings.Replace(tmpl, "%s", queryEscape(query), 1), true
+	}
+
+	// Fall back to the legacy single-URL setting for configs that haven't
+	// migrated to the [search] table yet.
+	if legacy := viper.GetString("a-general.search"); legacy != "" {
+		return legacy + "?" + queryEscape(query), true
+	}
+
+	return "", false
+}
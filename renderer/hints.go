@@ -0,0 +1,49 @@
+package renderer
+
+import "strings"
+
+// RenderGeminiHints re-renders raw Gemtext content with a short hint label
+// overlaid on every link line, in the same order the links are returned in,
+// so link-hint mode can decorate a page without re-fetching it. hints must
+// have at least as many entries as the page has links.
+//
+// This works on the raw Gemtext rather than already-wrapped Content, so it
+// can reuse RenderGemini for wrapping and coloring instead of re-doing that
+// work on pre-formatted text.
+func RenderGeminiHints(raw string, width, leftMargin int, hints []string) (string, []string) {
+	lines := strings.Split(raw, "\n")
+	link := 0
+	pre := false
+	for i, line := range lines {
+		if strings.HasPrefix(line, "```") {
+			pre = !pre
+			continue
+		}
+		if pre || !strings.HasPrefix(line, "=>") {
+			continue
+		}
+		if link >= len(hints) {
+			break
+		}
+		lines[i] = insertHintLabel(line, hints[link])
+		link++
+	}
+	return RenderGemini(strings.Join(lines, "\n"), width, leftMargin)
+}
+
+// insertHintLabel adds a bracketed hint label in front of the link name of
+// a Gemtext link line, keeping the URL in place, ex.
+// "=> gemini://example.com Example" becomes
+// "=> gemini://example.com [as] Example". A link with no name gets the URL
+// as its name, ex. "=> gemini://example.com [as] gemini://example.com".
+func insertHintLabel(line, hint string) string {
+	rest := strings.TrimSpace(strings.TrimPrefix(line, "=>"))
+	u, name := rest, ""
+	if i := strings.IndexAny(rest, " \t"); i >= 0 {
+		u, name = rest[:i], strings.TrimSpace(rest[i:])
+	}
+	if name == "" {
+		name = u
+	}
+	return "=> " + u + " [" + hint + "] " + name
+}